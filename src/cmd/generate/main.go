@@ -21,7 +21,9 @@ type Service struct {
 	Port            int      `yaml:"port"`
 	ProcessingDelay string   `yaml:"processing_delay"`
 	Replicas        int      `yaml:"replicas"`
+	RPS             int      `yaml:"rps"`
 	OutServices     []string `yaml:"out_services"`
+	Transport       string   `yaml:"transport"`
 }
 
 type TemplateData struct {
@@ -59,6 +61,26 @@ spec:
         runAsGroup: 3000  # matches the appgroup GID
         seccompProfile:
           type: RuntimeDefault
+      {{- if eq .Service.Transport "http3" }}
+      initContainers:
+      - name: gen-tls-cert
+        image: alpine/openssl
+        command:
+        - sh
+        - -c
+        - openssl req -x509 -newkey rsa:2048 -nodes -days 1 -subj "/CN={{ .Name }}" -keyout /etc/microbench/tls/tls.key -out /etc/microbench/tls/tls.crt
+        securityContext:
+          allowPrivilegeEscalation: false
+          runAsNonRoot: true
+          runAsUser: 1000
+          runAsGroup: 3000
+          capabilities:
+            drop:
+            - ALL
+        volumeMounts:
+        - name: tls
+          mountPath: /etc/microbench/tls
+      {{- end }}
       containers:
       - name: {{ .Name }}
         image: 134.59.129.87:4430/microbench:v2
@@ -83,8 +105,19 @@ spec:
         {{- end }}
         ports:
         - containerPort: {{ .Service.Port }}
+          {{- if eq .Service.Transport "http3" }}
+          protocol: UDP
+          {{- end }}
         - containerPort: 8000
         - containerPort: 8080
+        {{- if eq .Service.Transport "http3" }}
+        volumeMounts:
+        - name: tls
+          mountPath: /etc/microbench/tls
+      volumes:
+      - name: tls
+        emptyDir: {}
+        {{- end }}
       affinity:
         nodeAffinity:
           requiredDuringSchedulingIgnoredDuringExecution:
@@ -110,6 +143,9 @@ spec:
   ports:
   - port: {{ .Service.Port }}
     targetPort: {{ .Service.Port }}
+    {{- if eq .Service.Transport "http3" }}
+    protocol: UDP
+    {{- end }}
 {{ end }}
 
 {{- define "external-service" }}
@@ -169,6 +205,14 @@ func generateManifests(file, cniName, logLevel string) error {
 			data.Args = append(data.Args, "--is-frontend")
 		}
 
+		if service.Transport != "" {
+			data.Args = append(data.Args, fmt.Sprintf("--transport=%s", service.Transport))
+		}
+
+		if service.RPS > 0 {
+			data.Args = append(data.Args, fmt.Sprintf("--rps=%d", service.RPS))
+		}
+
 		if err := tmpl.ExecuteTemplate(os.Stdout, "statefulset", data); err != nil {
 			return err
 		}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -11,8 +12,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/KillianCdP/MicroBench/internal/topology"
+	"github.com/KillianCdP/MicroBench/pkg/faults"
+	"github.com/KillianCdP/MicroBench/pkg/loadgen"
 	pb "github.com/KillianCdP/MicroBench/pkg/proto"
-	"google.golang.org/grpc"
 
 	"github.com/KillianCdP/MicroBench/pkg/service"
 
@@ -20,7 +23,6 @@ import (
 	"github.com/valyala/fasthttp"
 	"github.com/valyala/fasthttp/pprofhandler"
 
-	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/sdk/resource"
@@ -90,6 +92,13 @@ func main() {
 	frontend := flag.Bool("is-frontend", false, "whether the service is the frontend")
 	pprof := flag.Bool("pprof", false, "Enable pprof server")
 	enableTracing := flag.Bool("tracing", false, "Enable OpenTelemetry tracing")
+	topologyProvider := flag.String("topology-provider", "", "Dynamic topology provider: file, consul, or etcd (disabled if empty)")
+	topologySource := flag.String("topology-source", "", "Path/prefix/key the topology provider watches (file path, consul KV prefix, or etcd key)")
+	lbPolicy := flag.String("lb-policy", string(service.LBRoundRobin), "Client-side load balancing policy: round_robin, least_pending, or p2c")
+	arrival := flag.String("arrival", string(loadgen.ArrivalPoisson), "Open-loop arrival process: poisson, uniform, or closed")
+	duration := flag.Duration("duration", 0, "Bound the load generator run (0 = unbounded)")
+	warmup := flag.Duration("warmup", 0, "Exclude this much of the start of the run from latency stats")
+	transportKind := flag.String("transport", "grpc", "Inter-service transport: grpc, http1, http2, or http3")
 	flag.Parse()
 
 	level := getLogLevelFromEnv()
@@ -98,9 +107,9 @@ func main() {
 		Level: level,
 	}))
 
-	topology := os.Getenv("BENCH_NAME")
-	if topology == "" {
-		topology = "unknown"
+	topologyName := os.Getenv("BENCH_NAME")
+	if topologyName == "" {
+		topologyName = "unknown"
 	}
 	cni := os.Getenv("CNI")
 	if cni == "" {
@@ -135,20 +144,77 @@ func main() {
 		processedOutServices = split
 	}
 
+	var topoProvider topology.Provider
+	if *topologyProvider != "" {
+		endpoints := []string{}
+		if raw := os.Getenv("TOPOLOGY_ENDPOINTS"); raw != "" {
+			endpoints = strings.Split(raw, ",")
+		}
+		var err error
+		topoProvider, err = topology.NewProvider(*topologyProvider, *topologySource, endpoints)
+		if err != nil {
+			log.Fatalf("Failed to create topology provider: %v", err)
+		}
+		defer topoProvider.Close()
+	}
+
 	config := service.ServiceConfig{
-		Name:            *name,
-		OutServices:     processedOutServices,
-		ProcessingDelay: *processingDelay,
-		RPS:             *rps,
-		Topology:        topology,
-		CNI:             cni,
-		Logger:          logger,
-		TracerProvider:  tp,
+		Name:             *name,
+		OutServices:      processedOutServices,
+		ProcessingDelay:  *processingDelay,
+		RPS:              *rps,
+		Topology:         topologyName,
+		CNI:              cni,
+		Logger:           logger,
+		TracerProvider:   tp,
+		TopologyProvider: topoProvider,
+		LBPolicy:         service.LBPolicy(*lbPolicy),
 	}
 
 	svc := service.NewService(config)
+
+	transport, err := service.NewTransport(*transportKind, svc.Process, *enableTracing)
+	if err != nil {
+		log.Fatalf("Failed to create transport: %v", err)
+	}
+	svc.SetTransport(transport)
+
 	svc.Preconnect()
 
+	go func() {
+		adminRouter := router.New()
+		adminRouter.POST("/faults", func(ctx *fasthttp.RequestCtx) {
+			var profile faults.Profile
+			if err := json.Unmarshal(ctx.PostBody(), &profile); err != nil {
+				ctx.Error(fmt.Sprintf("invalid fault profile: %v", err), fasthttp.StatusBadRequest)
+				return
+			}
+			svc.Faults().Set(&profile)
+			slog.Info("fault profile updated", "profile", profile)
+			ctx.SetStatusCode(fasthttp.StatusNoContent)
+		})
+		adminRouter.DELETE("/faults", func(ctx *fasthttp.RequestCtx) {
+			svc.Faults().Clear()
+			slog.Info("fault profile cleared")
+			ctx.SetStatusCode(fasthttp.StatusNoContent)
+		})
+
+		slog.Info("Starting admin server on :8080")
+		if err := fasthttp.ListenAndServe(":8080", adminRouter.Handler); err != nil {
+			log.Fatalf("Failed to start admin server: %v", err)
+		}
+	}()
+
+	if topoProvider != nil {
+		watchCtx, cancelWatch := context.WithCancel(context.Background())
+		defer cancelWatch()
+		go func() {
+			if err := svc.WatchTopology(watchCtx); err != nil && watchCtx.Err() == nil {
+				slog.Error("topology watch stopped", "error", err)
+			}
+		}()
+	}
+
 	if *pprof {
 		go func() {
 			pprofRouter := router.New()
@@ -162,11 +228,39 @@ func main() {
 	}
 
 	if *frontend {
+		// The generator is always constructed, even at --rps=0: a topology
+		// update can raise RPS later via svc.OnRPSChange, and that hot
+		// reload needs a live generator to hand the new rate to.
+		generator := loadgen.New(loadgen.Config{
+			RPS:      *rps,
+			Arrival:  loadgen.Arrival(*arrival),
+			Duration: *duration,
+			Warmup:   *warmup,
+		}, func(ctx context.Context) error {
+			req := &pb.Message{BenchId: "loadgen", From: *name}
+			_, err := svc.Process(ctx, req)
+			return err
+		})
+
+		svc.OnRPSChange(generator.SetRPS)
+
+		genCtx, cancelGen := context.WithCancel(context.Background())
+		defer cancelGen()
+		go generator.Run(genCtx)
+
 		go func() {
 			handler := func(ctx *fasthttp.RequestCtx) {
 				switch string(ctx.Path()) {
 				case "/":
 					svc.HandleHTTP(ctx)
+				case "/metrics":
+					ctx.SetContentType("text/plain; version=0.0.4")
+					fmt.Fprint(ctx, generator.Metrics())
+				case "/stats":
+					ctx.SetContentType("application/json")
+					if err := json.NewEncoder(ctx).Encode(generator.Snapshot()); err != nil {
+						ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+					}
 				default:
 					ctx.Error("Not found", fasthttp.StatusNotFound)
 				}
@@ -179,24 +273,26 @@ func main() {
 		}()
 	}
 
+	// Started after OnRPSChange is registered above, so the first snapshot
+	// can already hand its RPS to the generator.
+	if topoProvider != nil {
+		watchCtx, cancelWatch := context.WithCancel(context.Background())
+		defer cancelWatch()
+		go func() {
+			if err := svc.WatchTopology(watchCtx); err != nil && watchCtx.Err() == nil {
+				slog.Error("topology watch stopped", "error", err)
+			}
+		}()
+	}
+
 	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%s", "0.0.0.0", *listenPort))
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
-	var grpcServer *grpc.Server
-	if *enableTracing {
-		grpcServer = grpc.NewServer(
-			grpc.StatsHandler(otelgrpc.NewServerHandler()),
-		)
-	} else {
-		grpcServer = grpc.NewServer()
-	}
-	pb.RegisterBenchmarkServiceServer(grpcServer, svc)
-
-	log.Printf("Starting service %s, out services: %v, processing delay: %v, target RPS: %d\n",
-		*name, *outServices, *processingDelay, *rps)
-	if err := grpcServer.Serve(lis); err != nil {
+	log.Printf("Starting service %s (%s transport), out services: %v, processing delay: %v, target RPS: %d\n",
+		*name, *transportKind, *outServices, *processingDelay, *rps)
+	if err := transport.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve: %v", err)
 	}
 }
@@ -0,0 +1,282 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	consulapi "github.com/hashicorp/consul/api"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Provider watches an external source for topology changes and emits the
+// full Topology snapshot every time it changes. Implementations must keep
+// emitting on the returned channel until ctx is cancelled, at which point
+// the channel is closed.
+type Provider interface {
+	Watch(ctx context.Context) (<-chan Topology, error)
+	Close() error
+}
+
+// NewProvider builds a Provider for kind ("file", "consul", "etcd"). source
+// is the file path for "file", the KV prefix for "consul", and the key for
+// "etcd". Endpoints for consul/etcd are read from env vars by the caller
+// and passed in via endpoints.
+func NewProvider(kind, source string, endpoints []string) (Provider, error) {
+	switch kind {
+	case "", "file":
+		return newFileProvider(source)
+	case "consul":
+		return newConsulProvider(source, endpoints)
+	case "etcd":
+		return newEtcdProvider(source, endpoints)
+	default:
+		return nil, fmt.Errorf("unknown topology provider %q", kind)
+	}
+}
+
+// fileProvider watches a topology YAML file on disk with fsnotify and
+// re-reads it whenever it changes.
+type fileProvider struct {
+	path    string
+	watcher *fsnotify.Watcher
+}
+
+func newFileProvider(path string) (*fileProvider, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	// Watch the parent directory rather than the file itself. A
+	// ConfigMap-mounted file is updated by re-pointing a sibling "..data"
+	// symlink at a new directory, which doesn't reliably raise an event
+	// on the file's own path; watching the directory and comparing the
+	// resolved target in Watch below catches that swap too.
+	dir := filepath.Dir(path)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	return &fileProvider{path: path, watcher: watcher}, nil
+}
+
+func (p *fileProvider) Watch(ctx context.Context) (<-chan Topology, error) {
+	out := make(chan Topology)
+
+	initial, err := ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(out)
+
+		select {
+		case out <- *initial:
+		case <-ctx.Done():
+			return
+		}
+
+		// realTarget tracks where path currently resolves to, so a
+		// ConfigMap's atomic "..data" symlink swap is detected even
+		// though it doesn't raise a Write/Create event on path itself.
+		realTarget, _ := filepath.EvalSymlinks(p.path)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-p.watcher.Events:
+				if !ok {
+					return
+				}
+
+				directHit := filepath.Clean(event.Name) == filepath.Clean(p.path) &&
+					event.Op&(fsnotify.Write|fsnotify.Create) != 0
+
+				curTarget, _ := filepath.EvalSymlinks(p.path)
+				symlinkSwapped := curTarget != "" && curTarget != realTarget
+
+				if !directHit && !symlinkSwapped {
+					continue
+				}
+				realTarget = curTarget
+
+				topo, err := ReadFile(p.path)
+				if err != nil {
+					continue
+				}
+				select {
+				case out <- *topo:
+				case <-ctx.Done():
+					return
+				}
+			case <-p.watcher.Errors:
+				continue
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (p *fileProvider) Close() error {
+	return p.watcher.Close()
+}
+
+// consulProvider watches a KV prefix in Consul and decodes the value at
+// prefix+"/topology" as a Topology YAML document.
+type consulProvider struct {
+	client *consulapi.Client
+	key    string
+}
+
+func newConsulProvider(prefix string, endpoints []string) (*consulProvider, error) {
+	cfg := consulapi.DefaultConfig()
+	if len(endpoints) > 0 {
+		cfg.Address = endpoints[0]
+	}
+	client, err := consulapi.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consul client: %w", err)
+	}
+	return &consulProvider{client: client, key: strings.TrimSuffix(prefix, "/") + "/topology"}, nil
+}
+
+func (p *consulProvider) Watch(ctx context.Context) (<-chan Topology, error) {
+	out := make(chan Topology)
+	kv := p.client.KV()
+
+	go func() {
+		defer close(out)
+
+		var lastIndex uint64
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			pair, meta, err := kv.Get(p.key, (&consulapi.QueryOptions{
+				WaitIndex: lastIndex,
+				WaitTime:  time.Minute,
+			}).WithContext(ctx))
+			if err != nil {
+				time.Sleep(time.Second)
+				continue
+			}
+			if pair == nil || meta.LastIndex == lastIndex {
+				lastIndex = meta.LastIndex
+				continue
+			}
+			lastIndex = meta.LastIndex
+
+			var topo Topology
+			if err := yaml.Unmarshal(pair.Value, &topo); err != nil {
+				continue
+			}
+			normalizeNames(&topo)
+
+			select {
+			case out <- topo:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (p *consulProvider) Close() error {
+	return nil
+}
+
+// etcdProvider watches a single key in etcd and decodes its value as a
+// Topology YAML document.
+type etcdProvider struct {
+	client *clientv3.Client
+	key    string
+}
+
+func newEtcdProvider(key string, endpoints []string) (*etcdProvider, error) {
+	if len(endpoints) == 0 {
+		endpoints = []string{"127.0.0.1:2379"}
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create etcd client: %w", err)
+	}
+	return &etcdProvider{client: client, key: key}, nil
+}
+
+func (p *etcdProvider) Watch(ctx context.Context) (<-chan Topology, error) {
+	out := make(chan Topology)
+
+	resp, err := p.client.Get(ctx, p.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read initial value for %s: %w", p.key, err)
+	}
+
+	go func() {
+		defer close(out)
+
+		if len(resp.Kvs) > 0 {
+			var topo Topology
+			if err := yaml.Unmarshal(resp.Kvs[0].Value, &topo); err == nil {
+				normalizeNames(&topo)
+				select {
+				case out <- topo:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+
+		watchCh := p.client.Watch(ctx, p.key)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case wresp, ok := <-watchCh:
+				if !ok {
+					return
+				}
+				for _, ev := range wresp.Events {
+					var topo Topology
+					if err := yaml.Unmarshal(ev.Kv.Value, &topo); err != nil {
+						continue
+					}
+					normalizeNames(&topo)
+					select {
+					case out <- topo:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (p *etcdProvider) Close() error {
+	return p.client.Close()
+}
+
+func normalizeNames(topo *Topology) {
+	for name, svc := range topo.Services {
+		svc.Name = name
+		topo.Services[name] = svc
+	}
+}
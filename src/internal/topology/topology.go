@@ -3,16 +3,27 @@ package topology
 import (
 	"os"
 
+	"github.com/KillianCdP/MicroBench/pkg/faults"
 	"gopkg.in/yaml.v3"
 )
 
 type Service struct {
-	Name            string   `yaml:"-"`
-	Node            string   `yaml:"node"`
-	Replicas        int      `yaml:"replicas"`
-	Port            int      `yaml:"port"`
-	ProcessingDelay string   `yaml:"processing_delay"`
-	OutServices     []string `yaml:"out_services"`
+	Name            string `yaml:"-"`
+	Node            string `yaml:"node"`
+	Replicas        int    `yaml:"replicas"`
+	Port            int    `yaml:"port"`
+	ProcessingDelay string `yaml:"processing_delay"`
+	// RPS hot-reloads the frontend load generator's target rate. Zero
+	// means "leave the current rate alone" rather than "stop".
+	RPS         int      `yaml:"rps"`
+	OutServices []string `yaml:"out_services"`
+	// Transport selects the inter-service wire protocol: grpc (default),
+	// http1, http2, or http3.
+	Transport string `yaml:"transport"`
+	// Faults, if set, is applied once to every request this service
+	// handles, before it forwards the request downstream; the individual
+	// outbound calls are not faulted separately.
+	Faults *faults.Profile `yaml:"faults,omitempty"`
 }
 
 type Topology struct {
@@ -31,10 +42,7 @@ func ReadFile(filename string) (*Topology, error) {
 		return nil, err
 	}
 
-	for name, service := range topology.Services {
-		service.Name = name
-		topology.Services[name] = service
-	}
+	normalizeNames(&topology)
 
 	return &topology, nil
 }
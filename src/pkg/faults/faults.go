@@ -0,0 +1,201 @@
+// Package faults injects configurable latency, errors, and bandwidth
+// limits into a service's request handling.
+package faults
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Distribution is the latency distribution a Profile draws injected delay
+// from.
+type Distribution string
+
+const (
+	DistFixed     Distribution = "fixed"
+	DistNormal    Distribution = "normal"
+	DistLognormal Distribution = "lognormal"
+)
+
+// Latency describes how long to artificially delay a call before
+// forwarding it.
+type Latency struct {
+	Distribution Distribution  `yaml:"distribution" json:"distribution"`
+	Mean         time.Duration `yaml:"mean" json:"mean"`
+	StdDev       time.Duration `yaml:"stddev" json:"stddev"`
+}
+
+// draw samples one delay from the configured distribution.
+func (l Latency) draw() time.Duration {
+	switch l.Distribution {
+	case DistNormal:
+		d := time.Duration(rand.NormFloat64()*float64(l.StdDev)) + l.Mean
+		if d < 0 {
+			d = 0
+		}
+		return d
+	case DistLognormal:
+		d := time.Duration(math.Exp(rand.NormFloat64()*float64(l.StdDev) + math.Log(float64(l.Mean))))
+		if d < 0 {
+			d = 0
+		}
+		return d
+	case DistFixed:
+		fallthrough
+	default:
+		return l.Mean
+	}
+}
+
+// Profile is a fault injection configuration for one service, set either
+// from the topology file's `faults:` block or pushed live via the admin
+// API.
+type Profile struct {
+	Latency        *Latency      `yaml:"latency,omitempty" json:"latency,omitempty"`
+	ErrorRate      float64       `yaml:"error_rate,omitempty" json:"error_rate,omitempty"`
+	AbortCode      string        `yaml:"abort_code,omitempty" json:"abort_code,omitempty"`
+	BandwidthLimit int64         `yaml:"bandwidth_limit,omitempty" json:"bandwidth_limit,omitempty"` // bytes/sec, 0 = unlimited
+	Jitter         time.Duration `yaml:"jitter,omitempty" json:"jitter,omitempty"`
+}
+
+// Event records one fault that Apply actually injected, for the caller to
+// attach to an OTel span as a `fault.injected` span event.
+type Event struct {
+	Type   string
+	Params map[string]string
+}
+
+// Injector holds the currently active Profile for a service and applies it
+// to calls. The zero value has no active profile and is a no-op.
+type Injector struct {
+	profile atomic.Pointer[Profile]
+}
+
+// NewInjector returns an Injector with no active fault profile.
+func NewInjector() *Injector {
+	return &Injector{}
+}
+
+// Set installs p as the active profile. Passing nil is equivalent to Clear.
+func (i *Injector) Set(p *Profile) {
+	i.profile.Store(p)
+}
+
+// Clear removes any active fault profile.
+func (i *Injector) Clear() {
+	i.profile.Store(nil)
+}
+
+// Get returns the currently active profile, or nil if none is set.
+func (i *Injector) Get() *Profile {
+	return i.profile.Load()
+}
+
+// Apply injects latency and, possibly, an error according to the active
+// profile. It blocks for the injected latency (plus jitter) before
+// returning. The returned events describe what was injected so the caller
+// can record them on its span; err is non-nil if the profile says this
+// call should fail.
+func (i *Injector) Apply(ctx context.Context) ([]Event, error) {
+	return Apply(ctx, i.Get())
+}
+
+// Apply injects latency and, possibly, an error according to p. It blocks
+// for the injected latency (plus jitter) before returning. The returned
+// events describe what was injected so the caller can record them on its
+// span; err is non-nil if p says this call should fail. A nil p is a
+// no-op.
+func Apply(ctx context.Context, p *Profile) ([]Event, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	var events []Event
+
+	if p.Latency != nil {
+		delay := p.Latency.draw()
+		if p.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(p.Jitter)))
+		}
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return events, ctx.Err()
+			}
+			events = append(events, Event{
+				Type: "latency",
+				Params: map[string]string{
+					"distribution": string(p.Latency.Distribution),
+					"delay":        delay.String(),
+				},
+			})
+		}
+	}
+
+	if p.ErrorRate > 0 && rand.Float64() < p.ErrorRate {
+		code := codes.Unavailable
+		if p.AbortCode != "" {
+			if c, err := parseCode(p.AbortCode); err == nil {
+				code = c
+			}
+		}
+		events = append(events, Event{
+			Type: "error",
+			Params: map[string]string{
+				"code": code.String(),
+			},
+		})
+		return events, status.Error(code, "fault injected")
+	}
+
+	return events, nil
+}
+
+func parseCode(name string) (codes.Code, error) {
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return codes.OK, fmt.Errorf("unknown abort code %q", name)
+}
+
+// Throttle blocks long enough to simulate writing nbytes of response at p's
+// BandwidthLimit. It works regardless of transport, since callers that
+// don't expose their wire write (gRPC, HTTP/2, HTTP/3) can still
+// approximate the same bandwidth constraint by delaying before they hand
+// the response back. It returns the Event to record, or nil if nothing was
+// throttled; a nil p or non-positive BandwidthLimit/nbytes is a no-op.
+func Throttle(ctx context.Context, p *Profile, nbytes int) *Event {
+	if p == nil || p.BandwidthLimit <= 0 || nbytes <= 0 {
+		return nil
+	}
+
+	delay := time.Duration(float64(nbytes) / float64(p.BandwidthLimit) * float64(time.Second))
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+	}
+
+	return &Event{
+		Type: "bandwidth_limit",
+		Params: map[string]string{
+			"bytes_per_sec": strconv.FormatInt(p.BandwidthLimit, 10),
+			"bytes":         strconv.Itoa(nbytes),
+			"delay":         delay.String(),
+		},
+	}
+}
@@ -0,0 +1,277 @@
+// Package loadgen drives an open-loop synthetic request rate against the
+// frontend service.
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Arrival is the inter-arrival distribution used to schedule synthetic
+// requests.
+type Arrival string
+
+const (
+	ArrivalPoisson Arrival = "poisson"
+	ArrivalUniform Arrival = "uniform"
+	ArrivalClosed  Arrival = "closed"
+)
+
+// Config describes one load generation run.
+type Config struct {
+	RPS      int
+	Arrival  Arrival
+	Duration time.Duration
+	Warmup   time.Duration
+	Workers  int
+}
+
+// RequestFunc issues one synthetic benchmark request and returns its error,
+// if any. The generator times each call itself, from dispatch until
+// RequestFunc returns, and records that latency as measured; no
+// coordinated-omission correction is applied.
+type RequestFunc func(ctx context.Context) error
+
+// Generator issues RequestFunc calls at a target open-loop rate and tracks
+// latency in an HDR histogram.
+type Generator struct {
+	cfg     Config
+	request RequestFunc
+
+	mu           sync.Mutex
+	hist         *hdrhistogram.Histogram
+	sent         atomic.Int64
+	completed    atomic.Int64
+	failed       atomic.Int64
+	dropped      atomic.Int64
+	warmingUp    atomic.Bool
+	startedAt    time.Time
+	requestedRPS atomic.Int64
+}
+
+// New builds a Generator. Workers defaults to min(RPS, 256) if unset, or to
+// 256 if RPS starts at 0 (idle), since a later SetRPS/topology update can
+// raise the rate and shouldn't be bottlenecked by a worker pool sized for
+// zero.
+func New(cfg Config, request RequestFunc) *Generator {
+	if cfg.Arrival == "" {
+		cfg.Arrival = ArrivalPoisson
+	}
+	if cfg.Workers == 0 {
+		switch {
+		case cfg.RPS <= 0:
+			cfg.Workers = 256
+		case cfg.RPS > 256:
+			cfg.Workers = 256
+		default:
+			cfg.Workers = cfg.RPS
+		}
+	}
+
+	g := &Generator{
+		cfg:     cfg,
+		request: request,
+		hist:    hdrhistogram.New(1, int64(time.Minute/time.Microsecond), 3),
+	}
+	g.requestedRPS.Store(int64(cfg.RPS))
+	return g
+}
+
+// SetRPS changes the target request rate of a running (or not-yet-started)
+// generator; it takes effect on the next scheduled tick.
+func (g *Generator) SetRPS(rps int) {
+	if rps <= 0 {
+		return
+	}
+	g.requestedRPS.Store(int64(rps))
+}
+
+// Run drives the generator until ctx is cancelled or cfg.Duration elapses
+// (0 means unbounded). cfg.RPS starting at 0 just means idle, not disabled:
+// Run still blocks waiting for SetRPS to raise the rate above zero, so a
+// topology update can turn on a generator that started at rps=0. It blocks;
+// callers typically run it in a goroutine.
+func (g *Generator) Run(ctx context.Context) {
+	if g.cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.cfg.Duration)
+		defer cancel()
+	}
+
+	g.startedAt = time.Now()
+	g.warmingUp.Store(g.cfg.Warmup > 0)
+	if g.warmingUp.Load() {
+		time.AfterFunc(g.cfg.Warmup, func() { g.warmingUp.Store(false) })
+	}
+
+	sem := make(chan struct{}, g.cfg.Workers)
+	var wg sync.WaitGroup
+
+	const idlePoll = 100 * time.Millisecond
+
+	for {
+		rps := g.requestedRPS.Load()
+		if rps <= 0 {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				g.logSummary()
+				return
+			case <-time.After(idlePoll):
+			}
+			continue
+		}
+		interval := time.Second / time.Duration(rps)
+		wait := nextArrival(g.cfg.Arrival, interval)
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			g.logSummary()
+			return
+		case <-time.After(wait):
+		}
+
+		select {
+		case sem <- struct{}{}:
+		default:
+			// Every worker is busy: the target can't keep up with the
+			// requested rate. Drop this tick rather than block the
+			// open-loop schedule; dropped counts the backpressure so the
+			// summary reflects it instead of just under-reporting sent.
+			g.dropped.Add(1)
+			continue
+		}
+
+		g.sent.Add(1)
+		wg.Add(1)
+		go func(issuedAt time.Time) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := g.request(ctx)
+
+			latency := time.Since(issuedAt)
+			if err != nil {
+				g.failed.Add(1)
+				slog.Error("loadgen request failed", "error", err)
+				return
+			}
+			g.completed.Add(1)
+			if !g.warmingUp.Load() {
+				g.mu.Lock()
+				_ = g.hist.RecordValue(latency.Microseconds())
+				g.mu.Unlock()
+			}
+		}(time.Now())
+	}
+}
+
+func nextArrival(arrival Arrival, meanInterval time.Duration) time.Duration {
+	switch arrival {
+	case ArrivalUniform:
+		return meanInterval
+	case ArrivalClosed:
+		return 0
+	case ArrivalPoisson:
+		fallthrough
+	default:
+		// Exponential inter-arrival times with mean meanInterval give a
+		// Poisson arrival process.
+		d := time.Duration(-float64(meanInterval) * math.Log(rand.Float64()))
+		if d < 0 {
+			d = 0
+		}
+		return d
+	}
+}
+
+// Snapshot is the point-in-time state exposed at /stats and /metrics.
+type Snapshot struct {
+	RequestedRPS int64   `json:"requested_rps"`
+	Sent         int64   `json:"sent"`
+	Completed    int64   `json:"completed"`
+	Failed       int64   `json:"failed"`
+	Dropped      int64   `json:"dropped"`
+	ElapsedSec   float64 `json:"elapsed_sec"`
+	AchievedRPS  float64 `json:"achieved_rps"`
+	P50Micros    int64   `json:"p50_us"`
+	P90Micros    int64   `json:"p90_us"`
+	P99Micros    int64   `json:"p99_us"`
+	P999Micros   int64   `json:"p999_us"`
+}
+
+// Snapshot returns the current counters and percentile latencies.
+func (g *Generator) Snapshot() Snapshot {
+	g.mu.Lock()
+	p50 := g.hist.ValueAtQuantile(50)
+	p90 := g.hist.ValueAtQuantile(90)
+	p99 := g.hist.ValueAtQuantile(99)
+	p999 := g.hist.ValueAtQuantile(99.9)
+	g.mu.Unlock()
+
+	elapsed := time.Since(g.startedAt).Seconds()
+	completed := g.completed.Load()
+
+	var achieved float64
+	if elapsed > 0 {
+		achieved = float64(completed) / elapsed
+	}
+
+	return Snapshot{
+		RequestedRPS: g.requestedRPS.Load(),
+		Sent:         g.sent.Load(),
+		Completed:    completed,
+		Failed:       g.failed.Load(),
+		Dropped:      g.dropped.Load(),
+		ElapsedSec:   elapsed,
+		AchievedRPS:  achieved,
+		P50Micros:    p50,
+		P90Micros:    p90,
+		P99Micros:    p99,
+		P999Micros:   p999,
+	}
+}
+
+// Metrics renders the current snapshot as Prometheus text exposition format.
+func (g *Generator) Metrics() string {
+	s := g.Snapshot()
+	return fmt.Sprintf(
+		"microbench_loadgen_requested_rps %d\n"+
+			"microbench_loadgen_sent_total %d\n"+
+			"microbench_loadgen_completed_total %d\n"+
+			"microbench_loadgen_failed_total %d\n"+
+			"microbench_loadgen_dropped_total %d\n"+
+			"microbench_loadgen_achieved_rps %f\n"+
+			"microbench_loadgen_latency_microseconds{quantile=\"0.5\"} %d\n"+
+			"microbench_loadgen_latency_microseconds{quantile=\"0.9\"} %d\n"+
+			"microbench_loadgen_latency_microseconds{quantile=\"0.99\"} %d\n"+
+			"microbench_loadgen_latency_microseconds{quantile=\"0.999\"} %d\n",
+		s.RequestedRPS, s.Sent, s.Completed, s.Failed, s.Dropped, s.AchievedRPS,
+		s.P50Micros, s.P90Micros, s.P99Micros, s.P999Micros,
+	)
+}
+
+func (g *Generator) logSummary() {
+	s := g.Snapshot()
+	slog.Info("load generator finished",
+		"requested_rps", s.RequestedRPS,
+		"achieved_rps", s.AchievedRPS,
+		"sent", s.Sent,
+		"completed", s.Completed,
+		"failed", s.Failed,
+		"dropped", s.Dropped,
+		"p50_us", s.P50Micros,
+		"p90_us", s.P90Micros,
+		"p99_us", s.P99Micros,
+		"p999_us", s.P999Micros,
+	)
+}
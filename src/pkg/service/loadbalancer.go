@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// LBPolicy selects how a peerGroup picks a replica for a given RPC.
+type LBPolicy string
+
+const (
+	LBRoundRobin   LBPolicy = "round_robin"
+	LBLeastPending LBPolicy = "least_pending"
+	LBPowerOfTwo   LBPolicy = "p2c"
+)
+
+// peer is a single replica behind a service name plus the bookkeeping the
+// load balancing policies need. The Transport owns the actual connection
+// to addr and dials it lazily on first use.
+type peer struct {
+	addr    string
+	pending atomic.Int64
+}
+
+// peerGroup is the per-service pool of replica addresses that callService
+// picks from. serviceName resolves to a headless Service in Kubernetes, so
+// LookupHost returns one address per StatefulSet pod.
+type peerGroup struct {
+	mu      sync.RWMutex
+	peers   []*peer
+	policy  LBPolicy
+	rrIndex atomic.Uint64
+}
+
+func resolvePeers(serviceName string, port int) ([]*peer, error) {
+	addrs, err := net.LookupHost(serviceName)
+	if err != nil || len(addrs) == 0 {
+		// Fall back to the bare service name: either DNS doesn't expose
+		// per-pod records (ClusterIP service, local dev) or there's a
+		// single replica, and Kubernetes DNS will round-robin for us.
+		addrs = []string{serviceName}
+	}
+
+	peers := make([]*peer, 0, len(addrs))
+	for _, addr := range addrs {
+		peers = append(peers, &peer{addr: fmt.Sprintf("%s:%d", addr, port)})
+	}
+
+	return peers, nil
+}
+
+func newPeerGroup(peers []*peer, policy LBPolicy) *peerGroup {
+	if policy == "" {
+		policy = LBRoundRobin
+	}
+	return &peerGroup{peers: peers, policy: policy}
+}
+
+// pick selects one peer for the next RPC according to the group's policy.
+// Callers are expected to track the returned peer's pending count
+// themselves (see callService).
+func (pg *peerGroup) pick() (*peer, error) {
+	pg.mu.RLock()
+	defer pg.mu.RUnlock()
+
+	if len(pg.peers) == 0 {
+		return nil, fmt.Errorf("no peers available")
+	}
+	if len(pg.peers) == 1 {
+		return pg.peers[0], nil
+	}
+
+	switch pg.policy {
+	case LBLeastPending:
+		return pg.pickLeastPending(pg.peers), nil
+	case LBPowerOfTwo:
+		a := pg.peers[rand.Intn(len(pg.peers))]
+		b := pg.peers[rand.Intn(len(pg.peers))]
+		return pg.pickLeastPending([]*peer{a, b}), nil
+	case LBRoundRobin:
+		fallthrough
+	default:
+		idx := pg.rrIndex.Add(1) - 1
+		return pg.peers[idx%uint64(len(pg.peers))], nil
+	}
+}
+
+func (pg *peerGroup) pickLeastPending(candidates []*peer) *peer {
+	best := candidates[0]
+	for _, p := range candidates[1:] {
+		if p.pending.Load() < best.pending.Load() {
+			best = p
+		}
+	}
+	return best
+}
@@ -9,44 +9,58 @@ import (
 	"sync"
 	"time"
 
+	"github.com/KillianCdP/MicroBench/internal/topology"
+	"github.com/KillianCdP/MicroBench/pkg/faults"
 	pb "github.com/KillianCdP/MicroBench/pkg/proto"
 	"github.com/KillianCdP/MicroBench/pkg/tracing"
 	"github.com/valyala/fasthttp"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/keepalive"
 
 	"go.opentelemetry.io/otel/attribute"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 )
 
 type Service struct {
 	pb.UnimplementedBenchmarkServiceServer
-	name            string
+	name string
+
+	runtimeMu       sync.RWMutex
 	outServices     []string
 	processingDelay time.Duration
 	rps             int
-	connPool        map[string]*grpc.ClientConn
-	connPoolMu      sync.RWMutex
-	tracer          *tracing.Tracer
-	otelTracer      trace.Tracer
-	topology        string
-	cni             string
+
+	lbPolicy         LBPolicy
+	peerGroups       map[string]*peerGroup
+	peerGroupsMu     sync.RWMutex
+	transport        Transport
+	tracer           *tracing.Tracer
+	otelTracer       trace.Tracer
+	topology         string
+	cni              string
+	topologyProvider topology.Provider
+	faultInjector    *faults.Injector
+	rpsHandler       func(rps int)
 }
 
 type ServiceConfig struct {
-	Name            string
-	OutServices     []string
-	ProcessingDelay time.Duration
-	RPS             int
-	Logger          *slog.Logger
-	Topology        string
-	CNI             string
-	TracerProvider  *tracesdk.TracerProvider
+	Name             string
+	OutServices      []string
+	ProcessingDelay  time.Duration
+	RPS              int
+	Logger           *slog.Logger
+	Topology         string
+	CNI              string
+	TracerProvider   *tracesdk.TracerProvider
+	TopologyProvider topology.Provider
+	LBPolicy         LBPolicy
 }
 
+// defaultServicePort is the port callService dials on every peer, whatever
+// the transport; it matches the service binary's default --port.
+const defaultServicePort = 50051
+
 type TraceLog struct {
 	Topology       string `json:"topology"`
 	BenchID        string `json:"bench_id"`
@@ -66,56 +80,213 @@ func NewService(config ServiceConfig) *Service {
 		otelTracer = config.TracerProvider.Tracer("service-tracer")
 	}
 
-	return &Service{
-		name:            config.Name,
-		outServices:     config.OutServices,
-		processingDelay: config.ProcessingDelay,
-		rps:             config.RPS,
-		connPool:        make(map[string]*grpc.ClientConn),
-		tracer:          tracer,
-		otelTracer:      otelTracer,
-		topology:        config.Topology,
-		cni:             config.CNI,
+	lbPolicy := config.LBPolicy
+	if lbPolicy == "" {
+		lbPolicy = LBRoundRobin
+	}
+
+	svc := &Service{
+		name:             config.Name,
+		outServices:      config.OutServices,
+		processingDelay:  config.ProcessingDelay,
+		rps:              config.RPS,
+		lbPolicy:         lbPolicy,
+		peerGroups:       make(map[string]*peerGroup),
+		tracer:           tracer,
+		otelTracer:       otelTracer,
+		topology:         config.Topology,
+		cni:              config.CNI,
+		topologyProvider: config.TopologyProvider,
+		faultInjector:    faults.NewInjector(),
+	}
+
+	// Default to gRPC so callers that don't care about transport selection
+	// keep working unchanged; SetTransport lets main choose another one
+	// once the Service (and its Process handler) exists to bind to.
+	svc.transport = newGRPCTransport(svc.Process, false)
+
+	return svc
+}
+
+// SetTransport overrides the Transport used for outbound calls and inbound
+// serving. Call it before Preconnect/serving starts.
+func (s *Service) SetTransport(t Transport) {
+	s.transport = t
+}
+
+// Faults returns the service's fault injector, so callers (typically the
+// admin HTTP API) can push or clear a live fault profile.
+func (s *Service) Faults() *faults.Injector {
+	return s.faultInjector
+}
+
+// OnRPSChange registers handler to be called whenever a topology update
+// changes this service's target RPS. Call it before WatchTopology starts.
+func (s *Service) OnRPSChange(handler func(rps int)) {
+	s.runtimeMu.Lock()
+	s.rpsHandler = handler
+	s.runtimeMu.Unlock()
+}
+
+// WatchTopology subscribes to the service's topology provider, if one was
+// configured, and atomically applies each incoming snapshot until ctx is
+// cancelled. It blocks, so callers typically run it in a goroutine.
+func (s *Service) WatchTopology(ctx context.Context) error {
+	if s.topologyProvider == nil {
+		return nil
+	}
+
+	updates, err := s.topologyProvider.Watch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start topology watch: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case topo, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			svc, found := topo.Services[s.name]
+			if !found {
+				slog.Warn("topology update missing this service, ignoring", "service", s.name)
+				continue
+			}
+
+			if svc.Faults != nil {
+				s.faultInjector.Set(svc.Faults)
+			}
+
+			s.applyTopology(svc)
+		}
 	}
 }
 
+// applyTopology atomically swaps the mutable, hot-reloadable parts of the
+// service's configuration so in-flight RPCs never observe a half-updated
+// state.
+func (s *Service) applyTopology(svc topology.Service) {
+	delay, err := time.ParseDuration(svc.ProcessingDelay)
+	if err != nil {
+		slog.Error("invalid processing delay in topology update, keeping previous value", "service", s.name, "error", err)
+		delay = s.getProcessingDelay()
+	}
+
+	s.runtimeMu.Lock()
+	s.outServices = svc.OutServices
+	s.processingDelay = delay
+	rpsChanged := svc.RPS > 0 && svc.RPS != s.rps
+	if rpsChanged {
+		s.rps = svc.RPS
+	}
+	handler := s.rpsHandler
+	s.runtimeMu.Unlock()
+
+	if rpsChanged && handler != nil {
+		handler(svc.RPS)
+	}
+
+	slog.Info("applied topology update", "service", s.name, "out_services", svc.OutServices, "processing_delay", delay, "rps", svc.RPS)
+}
+
+func (s *Service) getOutServices() []string {
+	s.runtimeMu.RLock()
+	defer s.runtimeMu.RUnlock()
+	return s.outServices
+}
+
+func (s *Service) getProcessingDelay() time.Duration {
+	s.runtimeMu.RLock()
+	defer s.runtimeMu.RUnlock()
+	return s.processingDelay
+}
+
+// warmer is implemented by transports that support eagerly dialing a peer
+// ahead of the first real RPC.
+type warmer interface {
+	Warm(addr string) error
+}
+
 func (s *Service) Preconnect() {
-	for _, svc := range s.outServices {
-		if _, err := s.getConnection(svc); err != nil {
-			slog.Error("preconnect failed", "service", svc, "error", err)
-		} else {
-			slog.Info("preconnect success", "service", svc)
+	for _, svcName := range s.getOutServices() {
+		pg, err := s.getPeerGroup(svcName)
+		if err != nil {
+			slog.Error("preconnect failed", "service", svcName, "error", err)
+			continue
+		}
+
+		w, ok := s.transport.(warmer)
+		if !ok {
+			continue
+		}
+
+		for _, p := range pg.peers {
+			if err := w.Warm(p.addr); err != nil {
+				slog.Error("preconnect failed", "service", svcName, "addr", p.addr, "error", err)
+			} else {
+				slog.Info("preconnect success", "service", svcName, "addr", p.addr)
+			}
 		}
 	}
 }
 
 func (s *Service) callService(ctx context.Context, serviceName, benchID string, traceID string, depth int32) (*pb.Message, error) {
+	pg, err := s.getPeerGroup(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := pg.pick()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pick a peer for %s: %w", serviceName, err)
+	}
+
+	p.pending.Add(1)
+	defer p.pending.Add(-1)
+
 	if s.otelTracer != nil {
+		protocolName, protocolVersion := s.transport.Protocol()
 		var span trace.Span
 		ctx, span = s.otelTracer.Start(ctx, "call_service",
 			trace.WithAttributes(
-				semconv.RPCSystemKey.String("grpc"),
 				semconv.RPCServiceKey.String(serviceName),
+				semconv.NetworkProtocolNameKey.String(protocolName),
+				semconv.NetworkProtocolVersionKey.String(protocolVersion),
 				attribute.String("peer.service", serviceName),
+				attribute.String("peer.address", p.addr),
 				attribute.String("trace.id", traceID),
 				attribute.String("bench.id", benchID),
 			))
 		defer span.End()
 	}
 
-	conn, err := s.getConnection(serviceName)
-	if err != nil {
-		return nil, err
-	}
-
-	client := pb.NewBenchmarkServiceClient(conn)
 	req := &pb.Message{
 		From:    s.name,
 		BenchId: benchID,
 		TraceId: traceID,
 		Depth:   depth,
 	}
-	return client.Process(ctx, req)
+	return s.transport.Call(ctx, p.addr, req)
+}
+
+// recordFaultEvents attaches each injected fault to ctx's active span as a
+// fault.injected event, so traces make clear a failure came from injection
+// rather than real CNI behavior.
+func (s *Service) recordFaultEvents(ctx context.Context, events []faults.Event) {
+	if s.otelTracer == nil || len(events) == 0 {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	for _, ev := range events {
+		attrs := make([]attribute.KeyValue, 0, len(ev.Params)+1)
+		attrs = append(attrs, attribute.String("fault.type", ev.Type))
+		for k, v := range ev.Params {
+			attrs = append(attrs, attribute.String("fault."+k, v))
+		}
+		span.AddEvent("fault.injected", trace.WithAttributes(attrs...))
+	}
 }
 
 func (s *Service) Process(ctx context.Context, req *pb.Message) (*pb.Message, error) {
@@ -134,31 +305,45 @@ func (s *Service) Process(ctx context.Context, req *pb.Message) (*pb.Message, er
 
 	s.tracer.LogTrace("process_start", req.BenchId, req.TraceId, req.From)
 
-	if s.processingDelay > 0 && s.otelTracer != nil {
+	// Read both under one lock so a topology update can't land between them.
+	s.runtimeMu.RLock()
+	processingDelay, outServices := s.processingDelay, s.outServices
+	s.runtimeMu.RUnlock()
+	if processingDelay > 0 && s.otelTracer != nil {
 		_, processingSpan := s.otelTracer.Start(ctx, "processing_delay")
-		time.Sleep(s.processingDelay)
+		time.Sleep(processingDelay)
 		processingSpan.End()
 	} else {
-		time.Sleep(s.processingDelay)
+		time.Sleep(processingDelay)
+	}
+
+	if events, err := s.faultInjector.Apply(ctx); err != nil || len(events) > 0 {
+		s.recordFaultEvents(ctx, events)
+		if err != nil {
+			s.tracer.LogTrace("process_end", req.BenchId, req.TraceId, req.From)
+			return nil, err
+		}
 	}
 
 	thisDepth := req.Depth + 1
 
-	if len(s.outServices) == 0 {
+	if len(outServices) == 0 {
 		s.tracer.LogTrace("process_end", req.BenchId, req.TraceId, req.From)
-		return &pb.Message{
+		resp := &pb.Message{
 			From:    s.name,
 			BenchId: req.BenchId,
 			TraceId: req.TraceId,
 			Depth:   thisDepth,
-		}, nil
+		}
+		s.throttleResponse(ctx, resp)
+		return resp, nil
 	}
 
 	var wg sync.WaitGroup
-	responses := make(chan *pb.Message, len(s.outServices))
-	errors := make(chan error, len(s.outServices))
+	responses := make(chan *pb.Message, len(outServices))
+	errors := make(chan error, len(outServices))
 
-	for _, outService := range s.outServices {
+	for _, outService := range outServices {
 		wg.Add(1)
 		go func(serviceName string) {
 			defer wg.Done()
@@ -183,12 +368,25 @@ func (s *Service) Process(ctx context.Context, req *pb.Message) (*pb.Message, er
 
 	s.tracer.LogTrace("process_end", req.BenchId, req.TraceId, req.From)
 
-	return &pb.Message{
+	resp := &pb.Message{
 		BenchId: req.BenchId,
 		From:    s.name,
 		TraceId: req.TraceId,
 		Depth:   0,
-	}, nil
+	}
+	s.throttleResponse(ctx, resp)
+	return resp, nil
+}
+
+// throttleResponse approximates a bandwidth_limit fault by delaying
+// proportionally to resp's wire size before Process hands it back. Doing
+// this here, rather than at the write site, means every transport
+// (grpc, http1, http2, http3) is throttled the same way instead of only
+// whichever one happens to expose its response io.Writer.
+func (s *Service) throttleResponse(ctx context.Context, resp *pb.Message) {
+	if ev := faults.Throttle(ctx, s.faultInjector.Get(), proto.Size(resp)); ev != nil {
+		s.recordFaultEvents(ctx, []faults.Event{*ev})
+	}
 }
 
 func (s *Service) handle_http(ctx *fasthttp.RequestCtx) {
@@ -248,46 +446,42 @@ func (s *Service) handle_http(ctx *fasthttp.RequestCtx) {
 		"cni":         s.cni,
 	}
 
+	// bandwidth_limit is applied once, generically, inside Process (see
+	// faults.Throttle), so every transport is throttled the same way
+	// instead of just this HTTP response write.
 	if err := json.NewEncoder(ctx).Encode(response); err != nil {
 		ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
 		return
 	}
 }
 
-func (s *Service) getConnection(serviceName string) (*grpc.ClientConn, error) {
-	s.connPoolMu.RLock()
-	conn, exists := s.connPool[serviceName]
-	s.connPoolMu.RUnlock()
+// getPeerGroup returns the pool of replica addresses for serviceName,
+// resolving them on first use. Connections are dialed by the Transport.
+func (s *Service) getPeerGroup(serviceName string) (*peerGroup, error) {
+	s.peerGroupsMu.RLock()
+	pg, exists := s.peerGroups[serviceName]
+	s.peerGroupsMu.RUnlock()
 
 	if exists {
-		return conn, nil
+		return pg, nil
 	}
 
-	s.connPoolMu.Lock()
-	defer s.connPoolMu.Unlock()
-
-	// Check again in case another goroutine created the connection
-	if conn, exists := s.connPool[serviceName]; exists {
-		return conn, nil
-	}
+	s.peerGroupsMu.Lock()
+	defer s.peerGroupsMu.Unlock()
 
-	kacp := keepalive.ClientParameters{
-		Time:                5 * time.Minute, // send pings every 5 minutes
-		Timeout:             time.Second,     // wait 1 second for ping ack before considering the connection dead
-		PermitWithoutStream: true,            // send pings even without active streams
+	// Check again in case another goroutine created the group.
+	if pg, exists := s.peerGroups[serviceName]; exists {
+		return pg, nil
 	}
 
-	conn, err := grpc.NewClient(
-		fmt.Sprintf("%s:%d", serviceName, 50051),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithKeepaliveParams(kacp),
-	)
+	peers, err := resolvePeers(serviceName, defaultServicePort)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to %s: %v", serviceName, err)
+		return nil, err
 	}
 
-	s.connPool[serviceName] = conn
-	return conn, nil
+	pg = newPeerGroup(peers, s.lbPolicy)
+	s.peerGroups[serviceName] = pg
+	return pg, nil
 }
 
 func (s *Service) HandleHTTP(ctx *fasthttp.RequestCtx) {
@@ -0,0 +1,427 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	pb "github.com/KillianCdP/MicroBench/pkg/proto"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/protobuf/proto"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+)
+
+// processPath is the HTTP path the non-gRPC transports POST a request to.
+const processPath = "/process"
+
+// Handler is the signature services implement to answer an inbound
+// benchmark request, regardless of which Transport delivered it.
+type Handler func(ctx context.Context, req *pb.Message) (*pb.Message, error)
+
+// Transport carries benchmark requests between services. Call dials (or
+// reuses a connection to) addr and issues req; Serve accepts inbound
+// requests on lis and dispatches them to the Transport's Handler. Protocol
+// reports the `network.protocol.{name,version}` values callService should
+// tag outgoing spans with.
+type Transport interface {
+	Call(ctx context.Context, addr string, req *pb.Message) (*pb.Message, error)
+	Serve(lis net.Listener) error
+	Protocol() (name, version string)
+}
+
+// NewTransport builds a Transport for kind ("grpc", "http1", "http2",
+// "http3"). handler answers inbound requests once Serve is called.
+func NewTransport(kind string, handler Handler, enableTracing bool) (Transport, error) {
+	switch kind {
+	case "", "grpc":
+		return newGRPCTransport(handler, enableTracing), nil
+	case "http1":
+		return newHTTP1Transport(handler), nil
+	case "http2":
+		return newHTTP2Transport(handler), nil
+	case "http3":
+		return newHTTP3Transport(handler), nil
+	default:
+		return nil, fmt.Errorf("unknown transport %q", kind)
+	}
+}
+
+// grpcTransport is the original gRPC-over-TCP behavior, now behind the
+// Transport interface.
+type grpcTransport struct {
+	handler Handler
+	tracing bool
+
+	connPoolMu sync.RWMutex
+	connPool   map[string]*grpc.ClientConn
+}
+
+func newGRPCTransport(handler Handler, enableTracing bool) *grpcTransport {
+	return &grpcTransport{handler: handler, tracing: enableTracing, connPool: make(map[string]*grpc.ClientConn)}
+}
+
+func (t *grpcTransport) getConn(addr string) (*grpc.ClientConn, error) {
+	t.connPoolMu.RLock()
+	conn, exists := t.connPool[addr]
+	t.connPoolMu.RUnlock()
+	if exists {
+		return conn, nil
+	}
+
+	t.connPoolMu.Lock()
+	defer t.connPoolMu.Unlock()
+	if conn, exists := t.connPool[addr]; exists {
+		return conn, nil
+	}
+
+	kacp := keepalive.ClientParameters{
+		Time:                5 * time.Minute,
+		Timeout:             time.Second,
+		PermitWithoutStream: true,
+	}
+	conn, err := grpc.NewClient(
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(kacp),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	t.connPool[addr] = conn
+	return conn, nil
+}
+
+// Warm eagerly dials addr so the first real RPC doesn't pay connection
+// setup cost. Preconnect calls this when the active transport supports it.
+func (t *grpcTransport) Warm(addr string) error {
+	_, err := t.getConn(addr)
+	return err
+}
+
+func (t *grpcTransport) Call(ctx context.Context, addr string, req *pb.Message) (*pb.Message, error) {
+	conn, err := t.getConn(addr)
+	if err != nil {
+		return nil, err
+	}
+	return pb.NewBenchmarkServiceClient(conn).Process(ctx, req)
+}
+
+func (t *grpcTransport) Protocol() (name, version string) {
+	return "grpc", "2"
+}
+
+func (t *grpcTransport) Serve(lis net.Listener) error {
+	var opts []grpc.ServerOption
+	if t.tracing {
+		opts = append(opts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	}
+	grpcServer := grpc.NewServer(opts...)
+	pb.RegisterBenchmarkServiceServer(grpcServer, &grpcHandlerAdapter{handler: t.handler})
+	return grpcServer.Serve(lis)
+}
+
+// grpcHandlerAdapter lets a plain Handler func satisfy the generated
+// pb.BenchmarkServiceServer interface.
+type grpcHandlerAdapter struct {
+	pb.UnimplementedBenchmarkServiceServer
+	handler Handler
+}
+
+func (a *grpcHandlerAdapter) Process(ctx context.Context, req *pb.Message) (*pb.Message, error) {
+	return a.handler(ctx, req)
+}
+
+// http1Transport exchanges JSON-encoded messages over HTTP/1.1 using
+// fasthttp, for benchmarking CNI behavior without gRPC's HTTP/2 framing.
+type http1Transport struct {
+	handler Handler
+	client  *fasthttp.Client
+}
+
+func newHTTP1Transport(handler Handler) *http1Transport {
+	return &http1Transport{handler: handler, client: &fasthttp.Client{}}
+}
+
+func (t *http1Transport) Call(ctx context.Context, addr string, req *pb.Message) (*pb.Message, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq := fasthttp.AcquireRequest()
+	httpResp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(httpReq)
+	defer fasthttp.ReleaseResponse(httpResp)
+
+	httpReq.SetRequestURI(fmt.Sprintf("http://%s%s", addr, processPath))
+	httpReq.Header.SetMethod(fasthttp.MethodPost)
+	httpReq.Header.SetContentType("application/json")
+	httpReq.SetBody(body)
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(30 * time.Second)
+	}
+	if err := t.client.DoDeadline(httpReq, httpResp, deadline); err != nil {
+		return nil, fmt.Errorf("http1 call to %s failed: %w", addr, err)
+	}
+
+	var resp pb.Message
+	if err := json.Unmarshal(httpResp.Body(), &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &resp, nil
+}
+
+func (t *http1Transport) Protocol() (name, version string) {
+	return "http", "1.1"
+}
+
+func (t *http1Transport) Serve(lis net.Listener) error {
+	handler := func(ctx *fasthttp.RequestCtx) {
+		if string(ctx.Path()) != processPath {
+			ctx.Error("Not found", fasthttp.StatusNotFound)
+			return
+		}
+		var req pb.Message
+		if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+			return
+		}
+		resp, err := t.handler(ctx, &req)
+		if err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+			return
+		}
+		ctx.SetContentType("application/json")
+		if err := json.NewEncoder(ctx).Encode(resp); err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+		}
+	}
+	return fasthttp.Serve(lis, handler)
+}
+
+// http2Transport exchanges protobuf-encoded messages over HTTP/2 (cleartext)
+// using net/http, isolating the data-plane transport from gRPC's framing
+// and flow control.
+type http2Transport struct {
+	handler Handler
+	client  *http.Client
+}
+
+func newHTTP2Transport(handler Handler) *http2Transport {
+	return &http2Transport{
+		handler: handler,
+		client: &http.Client{
+			Transport: &http2.Transport{
+				AllowHTTP: true,
+				DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, network, addr)
+				},
+			},
+		},
+	}
+}
+
+func (t *http2Transport) Call(ctx context.Context, addr string, req *pb.Message) (*pb.Message, error) {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s%s", addr, processPath), newReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+
+	httpResp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("http2 call to %s failed: %w", addr, err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var resp pb.Message
+	if err := proto.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &resp, nil
+}
+
+func (t *http2Transport) Protocol() (name, version string) {
+	return "http", "2"
+}
+
+func (t *http2Transport) Serve(lis net.Listener) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(processPath, func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var req pb.Message
+		if err := proto.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := t.handler(r.Context(), &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respBody, err := proto.Marshal(resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(respBody)
+	})
+
+	h2s := &http2.Server{}
+	server := &http.Server{Handler: h2c.NewHandler(mux, h2s)}
+	return server.Serve(lis)
+}
+
+// http3Transport exchanges protobuf-encoded messages over HTTP/3 (QUIC), to
+// compare CNI behavior under a UDP-based transport.
+type http3Transport struct {
+	handler Handler
+	client  *http.Client
+}
+
+func newHTTP3Transport(handler Handler) *http3Transport {
+	return &http3Transport{
+		handler: handler,
+		client: &http.Client{Transport: &http3.RoundTripper{
+			// Pods dial each other by in-cluster DNS name against a
+			// self-signed cert; there's no public CA to verify against.
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}},
+	}
+}
+
+func (t *http3Transport) Call(ctx context.Context, addr string, req *pb.Message) (*pb.Message, error) {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("https://%s%s", addr, processPath), newReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+
+	httpResp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("http3 call to %s failed: %w", addr, err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var resp pb.Message
+	if err := proto.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &resp, nil
+}
+
+func (t *http3Transport) Protocol() (name, version string) {
+	return "http", "3"
+}
+
+// Serve ignores lis beyond reading its port: QUIC needs a UDP packet
+// conn, not the TCP listener the caller set up for the other transports.
+func (t *http3Transport) Serve(lis net.Listener) error {
+	addr, ok := lis.Addr().(*net.TCPAddr)
+	if !ok {
+		return fmt.Errorf("http3 transport requires a TCP-addressed listener to read the port from, got %T", lis.Addr())
+	}
+	lis.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(processPath, func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var req pb.Message
+		if err := proto.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := t.handler(r.Context(), &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respBody, err := proto.Marshal(resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(respBody)
+	})
+
+	server := &http3.Server{
+		Addr:    fmt.Sprintf(":%d", addr.Port),
+		Handler: mux,
+	}
+	return server.ListenAndServeTLS(selfSignedCertFile, selfSignedKeyFile)
+}
+
+func newReader(b []byte) *bytesReader {
+	return &bytesReader{b: b}
+}
+
+// bytesReader is a minimal io.ReadCloser over a byte slice so Call doesn't
+// need to pull in bytes.Reader's full API surface just for http.NewRequest.
+type bytesReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *bytesReader) Read(p []byte) (int, error) {
+	if r.pos >= len(r.b) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.b[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+func (r *bytesReader) Close() error { return nil }
+
+// selfSignedCertFile/selfSignedKeyFile point at the TLS material HTTP/3
+// requires; operators provide these via the service's deployment the same
+// way they would for any other TLS-terminating sidecar.
+const (
+	selfSignedCertFile = "/etc/microbench/tls/tls.crt"
+	selfSignedKeyFile  = "/etc/microbench/tls/tls.key"
+)